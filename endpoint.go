@@ -0,0 +1,27 @@
+package sshtun
+
+import (
+	"net"
+	"strconv"
+)
+
+// TCPEndpoint is an Endpoint backed by a TCP host:port address.
+type TCPEndpoint struct {
+	Host string
+	Port int
+}
+
+// NewTCPEndpoint creates a TCPEndpoint for the given host and port.
+func NewTCPEndpoint(host string, port int) *TCPEndpoint {
+	return &TCPEndpoint{Host: host, Port: port}
+}
+
+// Type implements Endpoint.
+func (e *TCPEndpoint) Type() string {
+	return "tcp"
+}
+
+// String implements Endpoint.
+func (e *TCPEndpoint) String() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
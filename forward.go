@@ -5,10 +5,28 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Direction indicates which way a copy error or byte count applies to.
+type Direction int
 
-	"golang.org/x/sync/errgroup"
+const (
+	// ToRemote is the direction from the local endpoint to the remote one.
+	ToRemote Direction = iota
+	// ToLocal is the direction from the remote endpoint to the local one.
+	ToLocal
 )
 
+func (d Direction) String() string {
+	if d == ToRemote {
+		return "to-remote"
+	}
+	return "to-local"
+}
+
 // TunneledConnState represents the state of the final connections made through the tunnel.
 type TunneledConnState struct {
 	// From is the address initating the connection.
@@ -17,10 +35,22 @@ type TunneledConnState struct {
 	Info string
 	// Error holds an error on the connection or nil if the connection is successful.
 	Error error
+	// Direction indicates which leg of the copy Error refers to. It is only
+	// meaningful when Error is set for a mid-connection copy failure.
+	Direction Direction
 	// Ready indicates if the connection is established.
 	Ready bool
 	// Closed indicates if the connection is closed.
 	Closed bool
+	// BytesToRemote is the number of bytes copied from the local side to the remote side.
+	BytesToRemote int64
+	// BytesToLocal is the number of bytes copied from the remote side to the local side.
+	BytesToLocal int64
+	// StartedAt is the time the connection was established.
+	StartedAt time.Time
+	// Duration is how long the connection was open for. It is only set on the
+	// final "connection closed" state event.
+	Duration time.Duration
 }
 
 func (s *TunneledConnState) String() string {
@@ -35,6 +65,14 @@ func (s *TunneledConnState) String() string {
 }
 
 func (tun *SSHTun) forward(fromConn net.Conn) {
+	tun.wg.Add(1)
+	defer tun.wg.Done()
+
+	id := atomic.AddInt64(&tun.connID, 1)
+	tracked := &trackedConn{fromConn: fromConn}
+	tun.registerConn(id, tracked)
+	defer tun.unregisterConn(id)
+
 	from := fromConn.RemoteAddr().String()
 
 	if tun.forwardType == Local {
@@ -43,26 +81,59 @@ func (tun *SSHTun) forward(fromConn net.Conn) {
 			Info: fmt.Sprintf("accepted %s connection", tun.local.Type()),
 		})
 	} else if tun.forwardType == Remote {
-    tun.tunneledState(&TunneledConnState{
-      From: from,
-      Info: fmt.Sprintf("accepted %s connection", tun.remote.Type()),
-    })
-  }
+		tun.tunneledState(&TunneledConnState{
+			From: from,
+			Info: fmt.Sprintf("accepted %s connection", tun.remote.Type()),
+		})
+	} else if tun.forwardType == Dynamic {
+		tun.tunneledState(&TunneledConnState{
+			From: from,
+			Info: fmt.Sprintf("accepted %s connection", tun.local.Type()),
+		})
+	}
 
 	var toConn net.Conn
 	var err error
-	
-	if tun.forwardType == Local {
-		toConn, err = tun.sshClient.Dial(tun.remote.Type(), tun.remote.String())
+	var remoteAddr string
+
+	if tun.forwardType == Dynamic {
+		remoteAddr, err = tun.socks5Handshake(fromConn)
 		if err != nil {
 			tun.tunneledState(&TunneledConnState{
 				From:  from,
-				Error: fmt.Errorf("remote dial %s to %s failed: %w", tun.remote.Type(), tun.remote.String(), err),
+				Error: fmt.Errorf("socks5 handshake failed: %w", err),
 			})
 
 			fromConn.Close()
 			return
 		}
+	} else {
+		remoteAddr = tun.remote.String()
+	}
+
+	if tun.forwardType == Local && tun.remote.Type() == "unix" {
+		toConn, err = sshDialUnix(tun.sshClient, remoteAddr)
+	} else if tun.forwardType == Local || tun.forwardType == Dynamic {
+		toConn, err = tun.sshClient.Dial("tcp", remoteAddr)
+	}
+
+	if tun.forwardType == Dynamic {
+		// The client is still waiting on the CONNECT reply: tell it the
+		// real outcome now that the target has actually been dialed,
+		// rather than having told it "succeeded" up front.
+		if replyErr := tun.socks5ReplyResult(fromConn, err); replyErr != nil && err == nil {
+			err = replyErr
+		}
+	}
+
+	if err != nil {
+		tun.tunneledState(&TunneledConnState{
+			From:  from,
+			Error: fmt.Errorf("remote dial to %s failed: %w", remoteAddr, err),
+		})
+
+		fromConn.Close()
+		return
 	}
 	if tun.forwardType == Remote {
 		toConn, err = net.Dial(tun.local.Type(), tun.local.String())
@@ -77,69 +148,110 @@ func (tun *SSHTun) forward(fromConn net.Conn) {
 		}
 	}
 
-	connStr := fmt.Sprintf("%s -(%s)> %s -(ssh)> %s -(%s)> %s", from, tun.local.Type(), tun.local.String(),
-		tun.server.String(), tun.remote.Type(), tun.remote.String())
+	tracked.setToConn(toConn)
+
+	var connStr string
+	if tun.forwardType == Dynamic {
+		connStr = fmt.Sprintf("%s -(%s)> %s -(ssh)> %s -(tcp)> %s", from, tun.local.Type(), tun.local.String(),
+			tun.server.String(), remoteAddr)
+	} else {
+		connStr = fmt.Sprintf("%s -(%s)> %s -(ssh)> %s -(%s)> %s", from, tun.local.Type(), tun.local.String(),
+			tun.server.String(), tun.remote.Type(), remoteAddr)
+	}
+
+	startedAt := time.Now()
 
 	tun.tunneledState(&TunneledConnState{
-		From:   from,
-		Info:   fmt.Sprintf("connection established: %s", connStr),
-		Ready:  true,
-		Closed: false,
+		From:      from,
+		Info:      fmt.Sprintf("connection established: %s", connStr),
+		Ready:     true,
+		Closed:    false,
+		StartedAt: startedAt,
 	})
 
 	connCtx, connCancel := context.WithCancel(tun.ctx)
-	errGroup := &errgroup.Group{}
 
-	errGroup.Go(func() error {
+	var wg sync.WaitGroup
+	var bytesToRemote, bytesToLocal int64
+	var copyErr atomic.Value // stores error
+
+	// In Remote mode the accepted conn is the remote side and the dialed one
+	// is the local side, so swap them to keep ToRemote meaning local->remote.
+	localConn, remoteConn := fromConn, toConn
+	if tun.forwardType == Remote {
+		localConn, remoteConn = toConn, fromConn
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
 		defer connCancel()
-		_, err = io.Copy(toConn, fromConn)
+		src, dst, err := tun.applyInterceptors(connCtx, localConn, remoteConn, ToRemote)
 		if err != nil {
-			if tun.forwardType == Local {
-				return fmt.Errorf("failed copying bytes from remote to local: %w", err)
-			} else if tun.forwardType == Remote {
-				return fmt.Errorf("failed copying bytes from local to remote: %w", err)
-			}
+			copyErr.Store(copyErrInfo{dir: ToRemote, err: fmt.Errorf("interceptor chain to remote failed: %w", err)})
+			return
 		}
-		return toConn.Close()
-	})
+		n, err := io.Copy(dst, src)
+		atomic.AddInt64(&bytesToRemote, n)
+		if err != nil {
+			copyErr.Store(copyErrInfo{dir: ToRemote, err: fmt.Errorf("failed copying bytes to remote: %w", err)})
+			return
+		}
+		remoteConn.Close()
+	}()
 
-	errGroup.Go(func() error {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
 		defer connCancel()
-		_, err = io.Copy(fromConn, toConn)
+		src, dst, err := tun.applyInterceptors(connCtx, remoteConn, localConn, ToLocal)
 		if err != nil {
-			if tun.forwardType == Local {
-				return fmt.Errorf("failed copying bytes from local to remote: %w", err)
-			} else if tun.forwardType == Remote {
-				return fmt.Errorf("failed copying bytes from remote to local: %w", err)
-			}
+			copyErr.Store(copyErrInfo{dir: ToLocal, err: fmt.Errorf("interceptor chain to local failed: %w", err)})
+			return
 		}
-		return fromConn.Close()
-	})
-
-	err = errGroup.Wait()
+		n, err := io.Copy(dst, src)
+		atomic.AddInt64(&bytesToLocal, n)
+		if err != nil {
+			copyErr.Store(copyErrInfo{dir: ToLocal, err: fmt.Errorf("failed copying bytes to local: %w", err)})
+			return
+		}
+		localConn.Close()
+	}()
 
+	wg.Wait()
 	<-connCtx.Done()
 
 	select {
 	case <-tun.ctx.Done():
 	default:
-		if err != nil {
+		if v := copyErr.Load(); v != nil {
+			info := v.(copyErrInfo)
 			tun.tunneledState(&TunneledConnState{
-				From:  from,
-				Error: err,
-				Closed: true,
+				From:      from,
+				Error:     info.err,
+				Direction: info.dir,
+				Closed:    true,
 			})
 		}
 	}
 
 	tun.tunneledState(&TunneledConnState{
-		From:   from,
-		Info:   fmt.Sprintf("connection closed: %s", connStr),
-		Ready:  false,
-		Closed: true,
+		From:          from,
+		Info:          fmt.Sprintf("connection closed: %s", connStr),
+		Ready:         false,
+		Closed:        true,
+		BytesToRemote: atomic.LoadInt64(&bytesToRemote),
+		BytesToLocal:  atomic.LoadInt64(&bytesToLocal),
+		StartedAt:     startedAt,
+		Duration:      time.Since(startedAt),
 	})
 }
 
+type copyErrInfo struct {
+	dir Direction
+	err error
+}
+
 func (tun *SSHTun) tunneledState(state *TunneledConnState) {
 	if tun.tunneledConnState != nil {
 		tun.tunneledConnState(tun, state)
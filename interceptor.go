@@ -0,0 +1,41 @@
+package sshtun
+
+import (
+	"context"
+	"net"
+)
+
+// ConnInterceptor lets callers transparently insert logic (TLS termination,
+// protocol sniffing, rate limiting, compression, logging, ...) in front of
+// the raw io.Copy that moves bytes for one direction of a tunneled
+// connection.
+//
+// Wrap is called once per direction with the two ends of that leg of the
+// copy (from is read from, to is written to) and returns the net.Conn pair
+// that should actually be used, optionally replacing either or both. Returning
+// the inputs unchanged is a valid, zero-overhead implementation.
+type ConnInterceptor interface {
+	Wrap(ctx context.Context, from, to net.Conn, dir Direction) (net.Conn, net.Conn, error)
+}
+
+// AddInterceptor appends a ConnInterceptor to the chain applied to every
+// tunneled connection. Interceptors are applied in the order they were
+// added, each one seeing the conns produced by the previous one. It must be
+// called before Start.
+func (tun *SSHTun) AddInterceptor(interceptor ConnInterceptor) {
+	tun.interceptors = append(tun.interceptors, interceptor)
+}
+
+// applyInterceptors runs the interceptor chain for one direction of a
+// tunneled connection, returning the (possibly wrapped) conns to use for the
+// io.Copy of that direction.
+func (tun *SSHTun) applyInterceptors(ctx context.Context, from, to net.Conn, dir Direction) (net.Conn, net.Conn, error) {
+	var err error
+	for _, interceptor := range tun.interceptors {
+		from, to, err = interceptor.Wrap(ctx, from, to, dir)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return from, to, nil
+}
@@ -0,0 +1,81 @@
+package sshtun
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// keepAliveRequestType is the global request sshtun uses to probe that the
+// underlying SSH transport is still alive, following the convention used by
+// the OpenSSH client.
+const keepAliveRequestType = "keepalive@openssh.com"
+
+// SetKeepAlive configures the interval at which keepalive probes are sent on
+// the SSH connection and how long to wait for a reply before considering the
+// connection dead. A dead connection is closed so the Start loop re-dials.
+//
+// It must be called before Start. Defaults are a 2 second interval and a 120
+// second max delay.
+func (tun *SSHTun) SetKeepAlive(interval, maxDelay time.Duration) {
+	tun.keepAliveInterval = interval
+	tun.keepAliveMaxDelay = maxDelay
+}
+
+// startKeepAlive starts the background keepalive loop for the current SSH
+// client. It is a no-op if the interval is zero or negative.
+func (tun *SSHTun) startKeepAlive() {
+	if tun.keepAliveInterval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(tun.ctx)
+	tun.keepAliveCancel = cancel
+
+	go func() {
+		ticker := time.NewTicker(tun.keepAliveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				replyCh := make(chan error, 1)
+				go func() {
+					_, _, err := tun.sshClient.SendRequest(keepAliveRequestType, true, nil)
+					replyCh <- err
+				}()
+
+				select {
+				case <-ctx.Done():
+					return
+				case err := <-replyCh:
+					if err != nil {
+						tun.tunneledState(&TunneledConnState{
+							Error: fmt.Errorf("keepalive probe failed: %w", err),
+						})
+						tun.sshClient.Close()
+						tun.stopAccepting()
+						return
+					}
+				case <-time.After(tun.keepAliveMaxDelay):
+					tun.tunneledState(&TunneledConnState{
+						Error: fmt.Errorf("keepalive probe timed out after %s", tun.keepAliveMaxDelay),
+					})
+					tun.sshClient.Close()
+					tun.stopAccepting()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// stopKeepAlive cancels the keepalive loop, if any is running.
+func (tun *SSHTun) stopKeepAlive() {
+	if tun.keepAliveCancel != nil {
+		tun.keepAliveCancel()
+		tun.keepAliveCancel = nil
+	}
+}
@@ -0,0 +1,134 @@
+package sshtun
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestSSHServer starts a real (in-process) SSH server on 127.0.0.1 that
+// accepts exactly one connection, never answers any global request
+// (including keepalive probes), and then stops listening so a subsequent
+// dial attempt fails with "connection refused". It returns the address to
+// dial and the ssh.ClientConfig to use.
+func newTestSSHServer(t *testing.T) (string, *ssh.ClientConfig) {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromSigner(priv)
+	if err != nil {
+		t.Fatalf("signer from key: %v", err)
+	}
+
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+
+	go func() {
+		conn, err := listener.Accept()
+		listener.Close()
+		if err != nil {
+			return
+		}
+
+		sshConn, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+		if err != nil {
+			return
+		}
+		defer sshConn.Close()
+
+		go func() {
+			for newCh := range chans {
+				newCh.Reject(ssh.Prohibited, "no channels in this test")
+			}
+		}()
+
+		// Deliberately never read from reqs, let alone reply: this is what
+		// makes the client's keepalive probe time out instead of getting an
+		// (even negative) answer.
+		_ = reqs
+
+		sshConn.Wait()
+	}()
+
+	clientConfig := &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         time.Second,
+	}
+
+	return addr, clientConfig
+}
+
+// TestKeepAliveFailureUnblocksLocalAcceptLoop reproduces the regression where
+// a dead keepalive only closed the SSH client, which never unblocked the
+// Local/Dynamic accept loop (it listens on a plain net.Listener, unrelated to
+// sshClient). Without stopAccepting() in the keepalive failure path, Start
+// never re-dials and this test times out; with the fix, Start observes the
+// dead transport, redials, finds the test server gone, and returns a dial
+// error.
+func TestKeepAliveFailureUnblocksLocalAcceptLoop(t *testing.T) {
+	serverAddr, clientConfig := newTestSSHServer(t)
+
+	localListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve local port: %v", err)
+	}
+	localAddr := localListener.Addr().String()
+	localListener.Close()
+
+	host, portStr, err := net.SplitHostPort(serverAddr)
+	if err != nil {
+		t.Fatalf("split server addr: %v", err)
+	}
+	serverPort, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("parse server port: %v", err)
+	}
+
+	localHost, localPortStr, err := net.SplitHostPort(localAddr)
+	if err != nil {
+		t.Fatalf("split local addr: %v", err)
+	}
+	localPort, err := strconv.Atoi(localPortStr)
+	if err != nil {
+		t.Fatalf("parse local port: %v", err)
+	}
+
+	tun := NewLocal(
+		NewTCPEndpoint(localHost, localPort),
+		NewTCPEndpoint(host, serverPort),
+		NewTCPEndpoint("127.0.0.1", 1),
+		clientConfig,
+	)
+	tun.SetKeepAlive(10*time.Millisecond, 50*time.Millisecond)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- tun.Start(context.Background())
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil || !strings.Contains(err.Error(), "ssh dial") {
+			t.Fatalf("expected a redial failure after the dead keepalive, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start never redialed after the keepalive probe timed out: accept loop stayed blocked")
+	}
+}
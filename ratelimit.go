@@ -0,0 +1,97 @@
+package sshtun
+
+import (
+	"context"
+	"net"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultRateLimiterBurst is used when NewRateLimiter is given a burst of
+// zero. It has to be independent of the configured rate: x/time/rate.Limiter
+// rejects any single WaitN call bigger than the bucket size, and a single
+// io.Copy read (32KB by default) would otherwise exceed low, real-world
+// caps, killing the connection instead of throttling it.
+const defaultRateLimiterBurst = 64 * 1024
+
+// RateLimiter is a ConnInterceptor that throttles the bytes copied through a
+// tunneled connection using a token-bucket algorithm. A per-connection limit
+// can be combined with a global limit shared across every connection
+// forwarded by the tunnel(s) this RateLimiter was added to (pass a
+// globalBytesPerSec of zero to only cap each connection individually).
+type RateLimiter struct {
+	global  *rate.Limiter
+	perConn int64
+	burst   int
+}
+
+// NewRateLimiter creates a RateLimiter that allows up to perConnBytesPerSec
+// bytes/sec in each direction of each individual connection (the two
+// directions have separate buckets) and, if globalBytesPerSec is greater
+// than zero, up to globalBytesPerSec bytes/sec across all connections and
+// both directions combined. A burst of zero defaults to defaultRateLimiterBurst.
+func NewRateLimiter(perConnBytesPerSec, globalBytesPerSec int64, burst int) *RateLimiter {
+	if burst <= 0 {
+		burst = defaultRateLimiterBurst
+	}
+
+	rl := &RateLimiter{perConn: perConnBytesPerSec, burst: burst}
+	if globalBytesPerSec > 0 {
+		rl.global = rate.NewLimiter(rate.Limit(globalBytesPerSec), burst)
+	}
+	return rl
+}
+
+// Wrap implements ConnInterceptor.
+func (rl *RateLimiter) Wrap(ctx context.Context, from, to net.Conn, dir Direction) (net.Conn, net.Conn, error) {
+	var connLimiter *rate.Limiter
+	if rl.perConn > 0 {
+		connLimiter = rate.NewLimiter(rate.Limit(rl.perConn), rl.burst)
+	}
+	return &rateLimitedConn{Conn: from, ctx: ctx, connLimiter: connLimiter, global: rl.global, burst: rl.burst}, to, nil
+}
+
+// rateLimitedConn wraps a net.Conn and blocks Read calls until the
+// configured limiters allow the bytes read to pass through.
+type rateLimitedConn struct {
+	net.Conn
+	ctx         context.Context
+	connLimiter *rate.Limiter
+	global      *rate.Limiter
+	burst       int
+}
+
+func (c *rateLimitedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		if werr := c.wait(n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// wait consumes n bytes worth of tokens from the configured limiters,
+// chunked to the limiter's burst size so a single large read (bigger than
+// the burst) is throttled over several waits instead of being rejected
+// outright by rate.Limiter.WaitN.
+func (c *rateLimitedConn) wait(n int) error {
+	for n > 0 {
+		chunk := n
+		if c.burst > 0 && chunk > c.burst {
+			chunk = c.burst
+		}
+		if c.connLimiter != nil {
+			if err := c.connLimiter.WaitN(c.ctx, chunk); err != nil {
+				return err
+			}
+		}
+		if c.global != nil {
+			if err := c.global.WaitN(c.ctx, chunk); err != nil {
+				return err
+			}
+		}
+		n -= chunk
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sshtun
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterCopiesMoreThanBurst(t *testing.T) {
+	const burst = 1024
+	const payloadSize = burst * 4
+
+	rl := NewRateLimiter(int64(burst)*100, 0, burst)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	wrapped, _, err := rl.Wrap(context.Background(), server, server, ToRemote)
+	if err != nil {
+		t.Fatalf("Wrap failed: %v", err)
+	}
+
+	payload := bytes.Repeat([]byte{0x42}, payloadSize)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Write(payload)
+		errCh <- err
+	}()
+
+	read, err := io.ReadAll(io.LimitReader(wrapped, int64(payloadSize)))
+	if err != nil {
+		t.Fatalf("read through rate-limited conn failed: %v", err)
+	}
+	if len(read) != payloadSize {
+		t.Fatalf("got %d bytes, want %d", len(read), payloadSize)
+	}
+	if !bytes.Equal(read, payload) {
+		t.Fatalf("payload corrupted through rate limiter")
+	}
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("client write failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for client write to complete")
+	}
+}
@@ -0,0 +1,93 @@
+package sshtun
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// trackedConn holds the two ends of a connection currently being forwarded,
+// so Shutdown can force-close them if they don't finish on their own.
+type trackedConn struct {
+	mu       sync.Mutex
+	fromConn net.Conn
+	toConn   net.Conn
+}
+
+func (c *trackedConn) setToConn(conn net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.toConn = conn
+}
+
+func (c *trackedConn) forceClose() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fromConn != nil {
+		c.fromConn.Close()
+	}
+	if c.toConn != nil {
+		c.toConn.Close()
+	}
+}
+
+func (tun *SSHTun) registerConn(id int64, c *trackedConn) {
+	tun.connsMu.Lock()
+	defer tun.connsMu.Unlock()
+	if tun.conns == nil {
+		tun.conns = make(map[int64]*trackedConn)
+	}
+	tun.conns[id] = c
+}
+
+func (tun *SSHTun) unregisterConn(id int64) {
+	tun.connsMu.Lock()
+	defer tun.connsMu.Unlock()
+	delete(tun.conns, id)
+}
+
+func (tun *SSHTun) forceCloseConns() int {
+	tun.connsMu.Lock()
+	defer tun.connsMu.Unlock()
+	n := len(tun.conns)
+	for _, c := range tun.conns {
+		c.forceClose()
+	}
+	return n
+}
+
+// Shutdown stops the tunnel from accepting new connections and waits, up to
+// ctx's deadline, for connections already being forwarded to finish on their
+// own. Any still open when ctx is done are force-closed. Start returns once
+// draining completes.
+//
+// Shutdown is meant for rolling restarts of long-lived services using
+// sshtun: call it from a signal handler instead of just cancelling the
+// context passed to Start, so in-flight traffic isn't cut off mid-copy.
+func (tun *SSHTun) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&tun.shuttingDown, 1)
+	tun.tunneledState(&TunneledConnState{Info: "draining"})
+
+	tun.stopAccepting()
+
+	done := make(chan struct{})
+	go func() {
+		tun.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		tun.tunneledState(&TunneledConnState{Info: "drained"})
+		return nil
+	case <-ctx.Done():
+		killed := tun.forceCloseConns()
+		tun.tunneledState(&TunneledConnState{Info: fmt.Sprintf("force-closed %d connection(s)", killed)})
+		if killed > 0 {
+			return fmt.Errorf("shutdown: force-closed %d connection(s) still in flight", killed)
+		}
+		return nil
+	}
+}
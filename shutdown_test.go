@@ -0,0 +1,97 @@
+package sshtun
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsInFlightConnections(t *testing.T) {
+	tun := &SSHTun{}
+
+	var mu sync.Mutex
+	var infos []string
+	tun.SetTunneledConnState(func(_ *SSHTun, state *TunneledConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		infos = append(infos, state.Info)
+	})
+
+	tun.wg.Add(1)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		tun.wg.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := tun.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if infos[0] != "draining" {
+		t.Fatalf("first state should be draining, got %q", infos[0])
+	}
+	if infos[len(infos)-1] != "drained" {
+		t.Fatalf("last state should be drained, got %q", infos[len(infos)-1])
+	}
+}
+
+func TestShutdownForceClosesAfterDeadline(t *testing.T) {
+	tun := &SSHTun{}
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	tun.wg.Add(1)
+	defer tun.wg.Done()
+	tun.registerConn(1, &trackedConn{fromConn: server})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := tun.Shutdown(ctx)
+	if err == nil || !strings.Contains(err.Error(), "force-closed") {
+		t.Fatalf("expected a force-closed error, got %v", err)
+	}
+
+	// The tracked conn should have been force-closed: a write against the
+	// other end of the pipe must now fail.
+	if _, werr := client.Write([]byte("x")); werr == nil {
+		t.Fatalf("expected write to closed conn to fail")
+	}
+}
+
+// TestAcceptCancelAccessIsRaceFree exercises setAcceptCancel and
+// stopAccepting concurrently, the way listenAndServe (on the Start
+// goroutine) and Shutdown/keepalive (called from other goroutines) do in
+// practice. Run with -race.
+func TestAcceptCancelAccessIsRaceFree(t *testing.T) {
+	tun := &SSHTun{ctx: context.Background()}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			_, cancel := context.WithCancel(tun.ctx)
+			tun.setAcceptCancel(cancel)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			tun.stopAccepting()
+		}
+	}()
+
+	wg.Wait()
+}
@@ -0,0 +1,185 @@
+package sshtun
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// SOCKS5 protocol constants, see RFC 1928 and RFC 1929.
+const (
+	socks5Version = 0x05
+
+	socks5MethodNoAuth       = 0x00
+	socks5MethodUserPass     = 0x02
+	socks5MethodNoAcceptable = 0xFF
+
+	socks5CmdConnect = 0x01
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+
+	socks5ReplySucceeded       = 0x00
+	socks5ReplyGeneralFailed   = 0x01
+	socks5ReplyConnRefused     = 0x05
+	socks5ReplyCmdNotSupported = 0x07
+
+	socks5UserPassVersion = 0x01
+)
+
+// socks5Handshake speaks the server side of a SOCKS5 negotiation on conn and
+// returns the "host:port" the client asked to CONNECT to. It does not reply
+// to a valid CONNECT request itself (see socks5ReplyResult) since the caller
+// hasn't dialed the target yet and doesn't know if it will succeed; only an
+// unsupported command or address type gets a failure reply here.
+func (tun *SSHTun) socks5Handshake(conn net.Conn) (string, error) {
+	if err := tun.socks5SelectMethod(conn); err != nil {
+		return "", fmt.Errorf("socks5 method negotiation failed: %w", err)
+	}
+
+	return tun.socks5ReadRequest(conn)
+}
+
+func (tun *SSHTun) socks5SelectMethod(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socks5Version {
+		return fmt.Errorf("unsupported socks version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+
+	requireAuth := tun.socks5User != "" || tun.socks5Pass != ""
+	wantMethod := byte(socks5MethodNoAuth)
+	if requireAuth {
+		wantMethod = socks5MethodUserPass
+	}
+
+	selected := byte(socks5MethodNoAcceptable)
+	for _, m := range methods {
+		if m == wantMethod {
+			selected = wantMethod
+			break
+		}
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, selected}); err != nil {
+		return err
+	}
+	if selected == socks5MethodNoAcceptable {
+		return errors.New("no acceptable authentication method")
+	}
+
+	if selected == socks5MethodUserPass {
+		return tun.socks5Authenticate(conn)
+	}
+	return nil
+}
+
+func (tun *SSHTun) socks5Authenticate(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+
+	user := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, user); err != nil {
+		return err
+	}
+
+	plen := make([]byte, 1)
+	if _, err := io.ReadFull(conn, plen); err != nil {
+		return err
+	}
+	pass := make([]byte, plen[0])
+	if _, err := io.ReadFull(conn, pass); err != nil {
+		return err
+	}
+
+	ok := string(user) == tun.socks5User && string(pass) == tun.socks5Pass
+	status := byte(1)
+	if ok {
+		status = 0
+	}
+	if _, err := conn.Write([]byte{socks5UserPassVersion, status}); err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid socks5 credentials")
+	}
+	return nil
+}
+
+func (tun *SSHTun) socks5ReadRequest(conn net.Conn) (string, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socks5Version {
+		return "", fmt.Errorf("unsupported socks version %d", header[0])
+	}
+	if header[1] != socks5CmdConnect {
+		tun.socks5Reply(conn, socks5ReplyCmdNotSupported)
+		return "", fmt.Errorf("unsupported socks5 command %d", header[1])
+	}
+
+	var host string
+	switch header[3] {
+	case socks5AddrIPv4:
+		addr := make([]byte, 4)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", err
+		}
+		host = net.IP(addr).String()
+	case socks5AddrIPv6:
+		addr := make([]byte, 16)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", err
+		}
+		host = net.IP(addr).String()
+	case socks5AddrDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return "", err
+		}
+		domain := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		tun.socks5Reply(conn, socks5ReplyGeneralFailed)
+		return "", fmt.Errorf("unsupported socks5 address type %d", header[3])
+	}
+
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBytes); err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(portBytes)
+
+	return net.JoinHostPort(host, fmt.Sprintf("%d", port)), nil
+}
+
+func (tun *SSHTun) socks5Reply(conn net.Conn, rep byte) error {
+	_, err := conn.Write([]byte{socks5Version, rep, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// socks5ReplyResult sends the final CONNECT reply once the target has
+// actually been dialed, reporting success or a connection-refused failure
+// to the client as RFC 1928 requires, instead of claiming success up front.
+func (tun *SSHTun) socks5ReplyResult(conn net.Conn, dialErr error) error {
+	if dialErr != nil {
+		return tun.socks5Reply(conn, socks5ReplyConnRefused)
+	}
+	return tun.socks5Reply(conn, socks5ReplySucceeded)
+}
@@ -0,0 +1,67 @@
+package sshtun
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSocks5HandshakeParsesDomainRequest(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	tun := &SSHTun{}
+
+	type result struct {
+		addr string
+		err  error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		addr, err := tun.socks5Handshake(server)
+		resultCh <- result{addr, err}
+	}()
+
+	// Greeting: version 5, 1 method, no-auth.
+	if _, err := client.Write([]byte{0x05, 0x01, 0x00}); err != nil {
+		t.Fatalf("write greeting: %v", err)
+	}
+
+	methodReply := make([]byte, 2)
+	if _, err := client.Read(methodReply); err != nil {
+		t.Fatalf("read method reply: %v", err)
+	}
+	if methodReply[0] != socks5Version || methodReply[1] != socks5MethodNoAuth {
+		t.Fatalf("unexpected method reply: %v", methodReply)
+	}
+
+	// CONNECT request to a domain name.
+	domain := "example.com"
+	req := []byte{socks5Version, socks5CmdConnect, 0x00, socks5AddrDomain, byte(len(domain))}
+	req = append(req, []byte(domain)...)
+	port := make([]byte, 2)
+	binary.BigEndian.PutUint16(port, 80)
+	req = append(req, port...)
+	if _, err := client.Write(req); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+
+	// socks5Handshake must not reply to the CONNECT request itself: that's
+	// the caller's job, once it knows whether the dial succeeded. A
+	// premature reply would show up here as a successful read.
+	client.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Fatalf("expected no reply before the target is dialed, got one")
+	}
+	client.SetReadDeadline(time.Time{})
+
+	res := <-resultCh
+	if res.err != nil {
+		t.Fatalf("socks5Handshake failed: %v", res.err)
+	}
+	if res.addr != "example.com:80" {
+		t.Fatalf("got addr %q, want %q", res.addr, "example.com:80")
+	}
+}
@@ -0,0 +1,292 @@
+package sshtun
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Endpoint represents one side of a tunneled connection (local, remote or
+// the SSH server itself).
+type Endpoint interface {
+	// Type returns the network type used to dial/listen on this endpoint
+	// (e.g. "tcp" or "unix").
+	Type() string
+	// String returns the address in a form suitable for net.Dial/net.Listen.
+	String() string
+}
+
+// forwardType represents the direction in which connections are forwarded
+// through the tunnel.
+type forwardType int
+
+const (
+	// Local forwards connections accepted on the local endpoint to the
+	// remote endpoint, dialed through the SSH server.
+	Local forwardType = iota
+	// Remote forwards connections accepted on the SSH server to the local
+	// endpoint.
+	Remote
+	// Dynamic turns the local endpoint into a SOCKS5 server (RFC 1928) that
+	// dials whatever target the client requests through the SSH server,
+	// making sshtun a drop-in replacement for `ssh -D`.
+	Dynamic
+)
+
+// SSHTun represents a SSH tunnel. Use one of the New* functions to instantiate
+// it, tweak its settings and then call Start to open and keep the tunnel alive.
+type SSHTun struct {
+	server Endpoint
+	local  Endpoint
+	remote Endpoint
+
+	forwardType forwardType
+
+	sshConfig *ssh.ClientConfig
+	sshClient *ssh.Client
+
+	keepAliveInterval time.Duration
+	keepAliveMaxDelay time.Duration
+	keepAliveCancel   context.CancelFunc
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	acceptMu            sync.Mutex
+	acceptCancel        context.CancelFunc
+	acceptStopRequested bool
+	shuttingDown        int32
+	wg           sync.WaitGroup
+	connID       int64
+	connsMu      sync.Mutex
+	conns        map[int64]*trackedConn
+
+	interceptors []ConnInterceptor
+
+	socks5User string
+	socks5Pass string
+
+	tunneledConnState func(tun *SSHTun, state *TunneledConnState)
+}
+
+// NewLocal creates a new SSHTun that listens on the local endpoint and
+// forwards accepted connections to the remote endpoint through the SSH
+// server.
+func NewLocal(local, server, remote Endpoint, sshConfig *ssh.ClientConfig) *SSHTun {
+	return &SSHTun{
+		server:            server,
+		local:             local,
+		remote:            remote,
+		forwardType:       Local,
+		sshConfig:         sshConfig,
+		keepAliveInterval: 2 * time.Second,
+		keepAliveMaxDelay: 120 * time.Second,
+	}
+}
+
+// NewRemote creates a new SSHTun that listens on the SSH server and forwards
+// accepted connections to the local endpoint.
+func NewRemote(local, server, remote Endpoint, sshConfig *ssh.ClientConfig) *SSHTun {
+	return &SSHTun{
+		server:            server,
+		local:             local,
+		remote:            remote,
+		forwardType:       Remote,
+		sshConfig:         sshConfig,
+		keepAliveInterval: 2 * time.Second,
+		keepAliveMaxDelay: 120 * time.Second,
+	}
+}
+
+// NewDynamic creates a new SSHTun that turns local into a SOCKS5 (RFC 1928)
+// server, dialing whatever target its clients request through the SSH
+// server. remote is ignored in this mode.
+func NewDynamic(local, server Endpoint, sshConfig *ssh.ClientConfig) *SSHTun {
+	return &SSHTun{
+		server:            server,
+		local:             local,
+		forwardType:       Dynamic,
+		sshConfig:         sshConfig,
+		keepAliveInterval: 2 * time.Second,
+		keepAliveMaxDelay: 120 * time.Second,
+	}
+}
+
+// SetSOCKS5Credentials requires clients of a Dynamic tunnel to authenticate
+// with the given username/password (RFC 1929) instead of the default
+// no-auth method. It must be called before Start.
+func (tun *SSHTun) SetSOCKS5Credentials(user, pass string) {
+	tun.socks5User = user
+	tun.socks5Pass = pass
+}
+
+// SetTunneledConnState sets a callback to be called whenever the state of a
+// tunneled connection changes.
+func (tun *SSHTun) SetTunneledConnState(f func(tun *SSHTun, state *TunneledConnState)) {
+	tun.tunneledConnState = f
+}
+
+// Start starts the tunnel and blocks until the passed context is done or an
+// unrecoverable error happens. It re-dials the SSH server whenever the
+// connection is lost, so it is safe to run in a long-lived goroutine.
+func (tun *SSHTun) Start(ctx context.Context) error {
+	tun.ctx, tun.cancel = context.WithCancel(ctx)
+	defer tun.cancel()
+
+	for {
+		select {
+		case <-tun.ctx.Done():
+			return nil
+		default:
+		}
+
+		if atomic.LoadInt32(&tun.shuttingDown) == 1 {
+			return nil
+		}
+
+		if err := tun.dial(); err != nil {
+			return fmt.Errorf("ssh dial %s failed: %w", tun.server.String(), err)
+		}
+
+		tun.resetAccepting()
+		tun.startKeepAlive()
+
+		err := tun.listenAndServe()
+
+		tun.stopKeepAlive()
+
+		if atomic.LoadInt32(&tun.shuttingDown) == 1 {
+			tun.wg.Wait()
+			tun.sshClient.Close()
+			return err
+		}
+
+		tun.sshClient.Close()
+
+		select {
+		case <-tun.ctx.Done():
+			return nil
+		default:
+		}
+
+		if err != nil {
+			tun.tunneledState(&TunneledConnState{Error: err})
+		}
+	}
+}
+
+func (tun *SSHTun) dial() error {
+	client, err := ssh.Dial(tun.server.Type(), tun.server.String(), tun.sshConfig)
+	if err != nil {
+		return err
+	}
+	tun.sshClient = client
+	return nil
+}
+
+// setAcceptCancel records the CancelFunc that stops the currently running
+// accept loop. It is written from the Start goroutine (once per dial) and
+// read from stopAccepting, which can be called concurrently from a
+// keepalive goroutine or from Shutdown, so access is guarded by acceptMu.
+//
+// A keepalive failure can happen before listenAndServe has even started
+// listening (e.g. a very short keepalive interval racing the initial SSH
+// handshake), in which case stopAccepting runs before there is a
+// CancelFunc to call. acceptStopRequested latches that intent so the
+// CancelFunc set afterwards is cancelled immediately instead of the signal
+// being lost.
+func (tun *SSHTun) setAcceptCancel(cancel context.CancelFunc) {
+	tun.acceptMu.Lock()
+	defer tun.acceptMu.Unlock()
+	if tun.acceptStopRequested {
+		cancel()
+		return
+	}
+	tun.acceptCancel = cancel
+}
+
+// stopAccepting cancels the currently running accept loop, if any, causing
+// listenAndServe to stop listening and return so Start can redial or exit.
+// If no accept loop is running yet, the request is latched so the next one
+// started via setAcceptCancel is cancelled immediately. Safe to call
+// concurrently with setAcceptCancel.
+func (tun *SSHTun) stopAccepting() {
+	tun.acceptMu.Lock()
+	tun.acceptStopRequested = true
+	cancel := tun.acceptCancel
+	tun.acceptMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// resetAccepting clears any stop latched by a previous dial cycle, so a
+// fresh accept loop for the new SSH connection isn't cancelled immediately.
+func (tun *SSHTun) resetAccepting() {
+	tun.acceptMu.Lock()
+	tun.acceptStopRequested = false
+	tun.acceptCancel = nil
+	tun.acceptMu.Unlock()
+}
+
+func (tun *SSHTun) listenAndServe() error {
+	var listenEndpoint Endpoint
+	var listener net.Listener
+	var err error
+
+	if tun.forwardType == Remote {
+		listenEndpoint = tun.remote
+		listener, err = tun.sshClient.Listen(listenEndpoint.Type(), listenEndpoint.String())
+	} else {
+		listenEndpoint = tun.local
+		listener, err = net.Listen(listenEndpoint.Type(), listenEndpoint.String())
+	}
+	if err != nil {
+		return fmt.Errorf("listen %s on %s failed: %w", listenEndpoint.Type(), listenEndpoint.String(), err)
+	}
+	defer listener.Close()
+
+	if unixEndpoint, ok := listenEndpoint.(*UnixEndpoint); ok && tun.forwardType != Remote {
+		if err := unixEndpoint.chmodChown(); err != nil {
+			return fmt.Errorf("configure socket %s failed: %w", unixEndpoint.Path, err)
+		}
+		defer os.Remove(unixEndpoint.Path)
+	}
+
+	acceptCtx, acceptCancel := context.WithCancel(tun.ctx)
+	tun.setAcceptCancel(acceptCancel)
+	defer acceptCancel()
+
+	go func() {
+		<-acceptCtx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-acceptCtx.Done():
+				return nil
+			default:
+				return fmt.Errorf("accept failed: %w", err)
+			}
+		}
+
+		go tun.forward(conn)
+	}
+}
+
+// Stop stops the tunnel, closing the SSH client and every connection it is
+// currently forwarding.
+func (tun *SSHTun) Stop() {
+	if tun.cancel != nil {
+		tun.cancel()
+	}
+}
@@ -0,0 +1,48 @@
+package sshtun
+
+import (
+	"context"
+	"io"
+	"net"
+)
+
+// Tee is a ConnInterceptor that writes a copy of every byte forwarded through
+// the tunnel to a user-supplied io.Writer, pcap-style, without altering the
+// data that reaches the other end. ToRemote and ToLocal bytes can be sent to
+// different writers (e.g. to label which direction a dump line came from).
+type Tee struct {
+	// ToRemote receives a copy of the bytes flowing from the local side to
+	// the remote side. It is ignored if nil.
+	ToRemote io.Writer
+	// ToLocal receives a copy of the bytes flowing from the remote side to
+	// the local side. It is ignored if nil.
+	ToLocal io.Writer
+}
+
+// Wrap implements ConnInterceptor.
+func (t *Tee) Wrap(ctx context.Context, from, to net.Conn, dir Direction) (net.Conn, net.Conn, error) {
+	var w io.Writer
+	if dir == ToRemote {
+		w = t.ToRemote
+	} else {
+		w = t.ToLocal
+	}
+	if w == nil {
+		return from, to, nil
+	}
+	return &teeConn{Conn: from, w: w}, to, nil
+}
+
+// teeConn wraps a net.Conn, mirroring every successful Read to w.
+type teeConn struct {
+	net.Conn
+	w io.Writer
+}
+
+func (c *teeConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.w.Write(p[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,56 @@
+package sshtun
+
+import "os"
+
+// UnixEndpoint is an Endpoint backed by a UNIX domain socket path, usable on
+// either side of the tunnel (e.g. listening on a local socket, or dialing a
+// remote one like /var/run/docker.sock).
+type UnixEndpoint struct {
+	Path string
+
+	// FileMode, if non-zero, is applied to Path after it is listened on.
+	FileMode os.FileMode
+	// UID and GID, if non-negative, are applied as the owner of Path after
+	// it is listened on.
+	UID int
+	GID int
+}
+
+// NewUnixEndpoint creates a UnixEndpoint for the given socket path with no
+// file mode/ownership changes applied.
+func NewUnixEndpoint(path string) *UnixEndpoint {
+	return &UnixEndpoint{Path: path, UID: -1, GID: -1}
+}
+
+// Type implements Endpoint.
+func (e *UnixEndpoint) Type() string {
+	return "unix"
+}
+
+// String implements Endpoint.
+func (e *UnixEndpoint) String() string {
+	return e.Path
+}
+
+// chmodChown applies the endpoint's configured FileMode/UID/GID to its
+// socket file, if set. It is called right after the socket is listened on.
+func (e *UnixEndpoint) chmodChown() error {
+	if e.FileMode != 0 {
+		if err := os.Chmod(e.Path, e.FileMode); err != nil {
+			return err
+		}
+	}
+	if e.UID >= 0 || e.GID >= 0 {
+		uid, gid := e.UID, e.GID
+		if uid < 0 {
+			uid = os.Getuid()
+		}
+		if gid < 0 {
+			gid = os.Getgid()
+		}
+		if err := os.Chown(e.Path, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}